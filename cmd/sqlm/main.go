@@ -0,0 +1,109 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Command sqlm is the command-line front end for the sqlm migration
+// library. Today it only implements "verify"; see sqlm.Verify for the
+// underlying API and a way to plug in a ScratchFactory for engines other
+// than the in-memory SQLite one this binary uses.
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/worldiety/sqlm"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "verify":
+		runVerify(os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: sqlm verify -target <sqlite-dsn> -migrations <dir>")
+}
+
+// runVerify wires sqlm.Verify to the command line: it loads the migration
+// tree from -migrations with LoadFS, opens -target as a sqlite database and
+// diffs it against a throwaway in-memory sqlite scratch database. Other
+// engines aren't wired up here - import the sqlm package directly and call
+// Verify with a ScratchFactory of your own if -target isn't sqlite.
+func runVerify(args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	targetDSN := fs.String("target", "", "sqlite DSN of the database believed to already run these migrations")
+	migrationsDir := fs.String("migrations", "", "directory of migration groups, one subdirectory per group")
+	_ = fs.Parse(args)
+
+	if *targetDSN == "" || *migrationsDir == "" {
+		usage()
+		os.Exit(2)
+	}
+
+	migrations, err := sqlm.LoadFS(os.DirFS(*migrationsDir), ".")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot load migrations from %s: %v\n", *migrationsDir, err)
+		os.Exit(1)
+	}
+
+	target, err := sql.Open("sqlite", *targetDSN)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot open target database: %v\n", err)
+		os.Exit(1)
+	}
+	defer target.Close()
+
+	report, err := sqlm.Verify(target, sqliteScratch, migrations...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot verify: %v\n", err)
+		os.Exit(1)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot encode drift report: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !report.Clean() {
+		os.Exit(1)
+	}
+}
+
+// sqliteScratch is a sqlm.ScratchFactory backed by a fresh in-memory sqlite
+// database, closed again once Verify is done with it.
+func sqliteScratch() (*sql.DB, func() error, error) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		return nil, nil, err
+	}
+	return db, db.Close, nil
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sqlm
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+// MigrationGroup bundles the migrations discovered for a single group, ready
+// to be handed to Apply/MustMigrate.
+type MigrationGroup struct {
+	Group      string
+	Migrations []Migration
+}
+
+// ScanFS discovers migrations below root in fsys, one group per immediate
+// subdirectory of root - the subdirectory name becomes the group. This lets
+// applications ship their migrations compiled into the binary with Go 1.16's
+// embed.FS (or any other fs.FS) instead of depending on a writable
+// filesystem at deploy time, e.g.:
+//
+//	//go:embed schema
+//	var schemaFS embed.FS
+//	migrations, err := sqlm.LoadFS(schemaFS, "schema")
+func ScanFS(fsys fs.FS, root string) ([]MigrationGroup, error) {
+	entries, err := fs.ReadDir(fsys, root)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read migrations root %s: %w", root, err)
+	}
+
+	var res []MigrationGroup
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		group := entry.Name()
+		groupDir := path.Join(root, group)
+
+		files, err := fs.ReadDir(fsys, groupDir)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read group %s: %w", group, err)
+		}
+
+		var names []string
+		for _, file := range files {
+			names = append(names, file.Name())
+		}
+
+		migrations, err := collectMigrations(group, names, func(name string) ([]byte, error) {
+			return fs.ReadFile(fsys, path.Join(groupDir, name))
+		})
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse migrations of group %s: %w", group, err)
+		}
+
+		res = append(res, MigrationGroup{Group: group, Migrations: migrations})
+	}
+	return res, nil
+}
+
+// LoadFS is a convenience wrapper around ScanFS that flattens every group's
+// migrations into a single slice, ready to be passed to Apply/MustMigrate.
+func LoadFS(fsys fs.FS, root string) ([]Migration, error) {
+	groups, err := ScanFS(fsys, root)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []Migration
+	for _, group := range groups {
+		res = append(res, group.Migrations...)
+	}
+	return res, nil
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sqlm_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/worldiety/sqlm"
+)
+
+func TestScanFSGroupsByImmediateSubdirectory(t *testing.T) {
+	fsys := fstest.MapFS{
+		"schema/widgets/1_up.sql":   {Data: []byte("CREATE TABLE widgets (id INTEGER);")},
+		"schema/widgets/1_down.sql": {Data: []byte("DROP TABLE widgets;")},
+		"schema/gadgets/1_up.sql":   {Data: []byte("CREATE TABLE gadgets (id INTEGER);")},
+	}
+
+	groups, err := sqlm.ScanFS(fsys, "schema")
+	if err != nil {
+		t.Fatalf("ScanFS failed: %v", err)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d: %+v", len(groups), groups)
+	}
+
+	byGroup := make(map[string]sqlm.MigrationGroup, len(groups))
+	for _, g := range groups {
+		byGroup[g.Group] = g
+	}
+
+	widgets, ok := byGroup["widgets"]
+	if !ok || len(widgets.Migrations) != 1 {
+		t.Fatalf("expected a single widgets migration, got %+v", widgets)
+	}
+	if widgets.Migrations[0].Statements[0] != "CREATE TABLE widgets (id INTEGER)" {
+		t.Errorf("unexpected up statement: %q", widgets.Migrations[0].Statements[0])
+	}
+	if widgets.Migrations[0].DownStatements[0] != "DROP TABLE widgets" {
+		t.Errorf("unexpected down statement: %q", widgets.Migrations[0].DownStatements[0])
+	}
+
+	gadgets, ok := byGroup["gadgets"]
+	if !ok || len(gadgets.Migrations) != 1 {
+		t.Fatalf("expected a single gadgets migration, got %+v", gadgets)
+	}
+}
+
+func TestLoadFSFlattensEveryGroup(t *testing.T) {
+	fsys := fstest.MapFS{
+		"schema/widgets/1_up.sql": {Data: []byte("CREATE TABLE widgets (id INTEGER);")},
+		"schema/gadgets/1_up.sql": {Data: []byte("CREATE TABLE gadgets (id INTEGER);")},
+	}
+
+	migrations, err := sqlm.LoadFS(fsys, "schema")
+	if err != nil {
+		t.Fatalf("LoadFS failed: %v", err)
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("expected 2 migrations, got %d: %+v", len(migrations), migrations)
+	}
+}
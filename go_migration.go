@@ -0,0 +1,44 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sqlm
+
+import (
+	"context"
+	"database/sql"
+)
+
+// RegisterGo builds a Go-code Migration that participates in the same
+// version ordering and migration_schema_history bookkeeping as SQL file
+// migrations, in the style of goose's Go migrations. Pass the returned
+// Migration to Apply/MustMigrate alongside your scanned SQL migrations.
+//
+// Unlike SQL migrations, a Go migration's checksum is derived from its
+// group, version and name rather than its source, so recompiling the
+// binary doesn't dirty already-applied history. up and down run inside the
+// same transaction as every other migration; down is only ever invoked via
+// Rollback, which requires the same Migration to be passed in again since Go
+// functions cannot be persisted into migration_schema_history.
+func RegisterGo(group string, version int64, name string, up func(ctx context.Context, tx *sql.Tx) error, down func(ctx context.Context, tx *sql.Tx) error) Migration {
+	return Migration{
+		Group:      group,
+		Version:    version,
+		ScriptName: name,
+		Type:       Go,
+		GoUp:       up,
+		GoDown:     down,
+	}
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sqlm_test
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/worldiety/sqlm"
+)
+
+func TestRegisterGoAppliesAndRollsBack(t *testing.T) {
+	db := openSQLite(t)
+
+	goMigration := sqlm.RegisterGo("widgets", 1, "create_widgets",
+		func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "CREATE TABLE widgets (id INTEGER PRIMARY KEY)")
+			return err
+		},
+		func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "DROP TABLE widgets")
+			return err
+		},
+	)
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("cannot begin tx: %v", err)
+	}
+	if err := sqlm.Apply(tx, goMigration); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("cannot commit: %v", err)
+	}
+	if !tableExists(t, db, "widgets") {
+		t.Fatalf("expected widgets table to exist after Apply")
+	}
+
+	// Rolling back without supplying the Migration must fail: GoDown isn't
+	// persisted in history.
+	if err := sqlm.Rollback(db, 0, "widgets"); err == nil {
+		t.Fatalf("expected Rollback without the Migration to fail")
+	} else if !strings.Contains(err.Error(), "no down function") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tx, err = db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("cannot begin tx: %v", err)
+	}
+	if err := sqlm.Rollback(tx, 0, "widgets", goMigration); err != nil {
+		t.Fatalf("Rollback with the Migration failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("cannot commit: %v", err)
+	}
+
+	if tableExists(t, db, "widgets") {
+		t.Fatalf("expected widgets table to be gone after Rollback")
+	}
+}
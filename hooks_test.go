@@ -0,0 +1,88 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sqlm_test
+
+import (
+	"testing"
+
+	"github.com/worldiety/sqlm"
+)
+
+func TestApplyWithOptionsInvokesHooksOnSuccess(t *testing.T) {
+	db := openSQLite(t)
+
+	var before, after []sqlm.Migration
+	opts := sqlm.ApplyOptions{
+		Hooks: sqlm.Hooks{
+			BeforeApply: func(m sqlm.Migration) { before = append(before, m) },
+			AfterApply:  func(m sqlm.Migration, entry sqlm.HistoryEntry) { after = append(after, m) },
+			OnFailure:   func(m sqlm.Migration, err error) { t.Fatalf("OnFailure must not be called: %v", err) },
+		},
+	}
+
+	m := sqlm.Migration{
+		Group:      "widgets",
+		Version:    1,
+		Statements: []string{"CREATE TABLE widgets (id INTEGER PRIMARY KEY)"},
+	}
+
+	if err := sqlm.ApplyWithOptions(db, opts, m); err != nil {
+		t.Fatalf("ApplyWithOptions failed: %v", err)
+	}
+
+	if len(before) != 1 || before[0].Version != 1 {
+		t.Fatalf("expected BeforeApply to be called once with version 1, got %+v", before)
+	}
+	if len(after) != 1 || after[0].Version != 1 {
+		t.Fatalf("expected AfterApply to be called once with version 1, got %+v", after)
+	}
+}
+
+func TestApplyWithOptionsInvokesOnFailure(t *testing.T) {
+	db := openSQLite(t)
+
+	var failed sqlm.Migration
+	var failErr error
+	opts := sqlm.ApplyOptions{
+		Hooks: sqlm.Hooks{
+			AfterApply: func(m sqlm.Migration, entry sqlm.HistoryEntry) {
+				t.Fatalf("AfterApply must not be called for a failing migration")
+			},
+			OnFailure: func(m sqlm.Migration, err error) {
+				failed = m
+				failErr = err
+			},
+		},
+	}
+
+	m := sqlm.Migration{
+		Group:      "widgets",
+		Version:    1,
+		Statements: []string{"NOT VALID SQL"},
+	}
+
+	if err := sqlm.ApplyWithOptions(db, opts, m); err == nil {
+		t.Fatalf("expected ApplyWithOptions to fail for invalid SQL")
+	}
+
+	if failed.Version != 1 {
+		t.Fatalf("expected OnFailure to be called with version 1, got %+v", failed)
+	}
+	if failErr == nil {
+		t.Fatalf("expected OnFailure to receive the underlying error")
+	}
+}
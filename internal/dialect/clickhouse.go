@@ -0,0 +1,95 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dialect
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ClickHouse is the Dialect for ClickHouse.
+type ClickHouse struct{}
+
+func (d ClickHouse) CreateTable(table string) string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s"+`
+(
+    `+"`group`"+`              String,
+    `+"`version`"+`            Int64,
+    `+"`script`"+`             String,
+    `+"`type`"+`               String,
+    `+"`checksum`"+`           String,
+    `+"`applied_at`"+`         DateTime,
+    `+"`execution_duration`"+` Int64,
+    `+"`status`"+`             String,
+    `+"`log`"+`                String,
+    `+"`down_script`"+`        String,
+    `+"`down_checksum`"+`      String
+)
+ENGINE = MergeTree
+ORDER BY (`+"`group`"+`, `+"`version`"+`)`, d.QuoteIdent(table))
+}
+
+func (d ClickHouse) InsertHistory(table string) string { return insertHistory(d, table) }
+
+// UpdateHistory uses ClickHouse's ALTER TABLE ... UPDATE mutation syntax
+// instead of a standard UPDATE statement. The column/argument order matches
+// every other dialect's UpdateHistory: the non-key columns, then group and
+// version for the WHERE clause.
+//
+// Unlike every other dialect, this mutation is applied asynchronously by
+// ClickHouse - a row read back immediately afterwards (e.g. Apply's dirty
+// check, or History()) can still observe the pre-mutation row, including a
+// stale "executing" Status. Callers relying on read-your-write consistency
+// of migration_schema_history on ClickHouse should account for this lag.
+func (d ClickHouse) UpdateHistory(table string) string {
+	setColumns := historyColumns[2:]
+	sets := make([]string, len(setColumns))
+	for i, c := range setColumns {
+		sets[i] = fmt.Sprintf("%s=%s", d.QuoteIdent(c), d.Placeholder(i+1))
+	}
+	return fmt.Sprintf(
+		`ALTER TABLE %s UPDATE %s WHERE %s=%s and %s=%s`,
+		d.QuoteIdent(table), strings.Join(sets, ", "),
+		d.QuoteIdent("group"), d.Placeholder(len(setColumns)+1),
+		d.QuoteIdent("version"), d.Placeholder(len(setColumns)+2),
+	)
+}
+
+func (d ClickHouse) SelectHistory(table string) string { return selectHistory(d, table) }
+
+func (d ClickHouse) Placeholder(n int) string   { return "?" }
+func (d ClickHouse) QuoteIdent(s string) string { return "`" + s + "`" }
+
+// IntrospectColumns leaves is_nullable empty: ClickHouse reports nullability
+// as part of the type itself (Nullable(T)) rather than as a separate column,
+// so nullability drift still shows up as a data_type change instead.
+func (d ClickHouse) IntrospectColumns(excludeTable string) string {
+	return fmt.Sprintf(`SELECT table, name, type, ''
+FROM system.columns
+WHERE database = currentDatabase() AND table != '%s'
+ORDER BY table, position`, excludeTable)
+}
+
+// IntrospectIndexes reports ClickHouse's data-skipping indexes, which are a
+// different concept from the unique/non-unique indexes other dialects have;
+// is_unique is always "NO".
+func (d ClickHouse) IntrospectIndexes(excludeTable string) string {
+	return fmt.Sprintf(`SELECT table, name, expr, 'NO'
+FROM system.data_skipping_indices
+WHERE database = currentDatabase() AND table != '%s'
+ORDER BY table, name`, excludeTable)
+}
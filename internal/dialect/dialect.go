@@ -0,0 +1,124 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package dialect renders the SQL statements sqlm needs against a specific
+// database engine. Implementations are pure string builders - they never
+// talk to a database themselves - following the approach goose takes with
+// its internal dialectquery package.
+package dialect
+
+import (
+	"fmt"
+	"strings"
+)
+
+// historyColumns is the fixed column order of the migration_schema_history
+// bookkeeping table, shared by every dialect. Callers always bind their
+// arguments in this order, regardless of which dialect renders the
+// placeholders.
+var historyColumns = []string{
+	"group", "version", "script", "type", "checksum", "applied_at",
+	"execution_duration", "status", "log", "down_script", "down_checksum",
+}
+
+// Dialect renders the SQL statements sqlm needs to create and maintain its
+// migration_schema_history table on a specific database engine.
+type Dialect interface {
+	CreateTable(table string) string
+	InsertHistory(table string) string
+	UpdateHistory(table string) string
+	SelectHistory(table string) string
+	Placeholder(n int) string
+	QuoteIdent(s string) string
+
+	// IntrospectColumns returns a query listing every user table's columns
+	// as (table_name, column_name, data_type, is_nullable ["YES"/"NO"]),
+	// ordered by table then column position. excludeTable is omitted from
+	// the result so the migration bookkeeping table itself never shows up
+	// as drift.
+	IntrospectColumns(excludeTable string) string
+
+	// IntrospectIndexes returns a query listing every user table's indexes
+	// as (table_name, index_name, column_name, is_unique ["YES"/"NO"]),
+	// ordered so that a single index's columns stay adjacent. excludeTable
+	// is omitted from the result for the same reason as IntrospectColumns.
+	IntrospectIndexes(excludeTable string) string
+}
+
+var registry = map[string]Dialect{
+	"postgresql": Postgres{},
+	"mysql":      MySQL{},
+	"sqlite":     SQLite{},
+	"clickhouse": ClickHouse{},
+	"sqlserver":  SQLServer{},
+}
+
+// RegisterDialect makes a custom Dialect available under name, overwriting
+// any built-in dialect already registered under that name. Use this to teach
+// sqlm about a database engine it doesn't ship support for.
+func RegisterDialect(name string, d Dialect) {
+	registry[name] = d
+}
+
+// Lookup returns the Dialect registered under name, if any.
+func Lookup(name string) (Dialect, bool) {
+	d, ok := registry[name]
+	return d, ok
+}
+
+func quotedColumns(d Dialect, columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = d.QuoteIdent(c)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// insertHistory builds the generic "INSERT INTO <table> (...) VALUES (...)"
+// statement shared by every dialect, using its own placeholder and quoting
+// rules. The argument order always matches historyColumns.
+func insertHistory(d Dialect, table string) string {
+	placeholders := make([]string, len(historyColumns))
+	for i := range historyColumns {
+		placeholders[i] = d.Placeholder(i + 1)
+	}
+	return fmt.Sprintf(
+		`INSERT INTO %s (%s) VALUES (%s)`,
+		d.QuoteIdent(table), quotedColumns(d, historyColumns), strings.Join(placeholders, ","),
+	)
+}
+
+// updateHistory builds the generic "UPDATE <table> SET ... WHERE group=? and
+// version=?" statement shared by every dialect. The argument order is every
+// column but group/version (which identify the row), followed by group and
+// version themselves.
+func updateHistory(d Dialect, table string) string {
+	setColumns := historyColumns[2:]
+	sets := make([]string, len(setColumns))
+	for i, c := range setColumns {
+		sets[i] = fmt.Sprintf("%s=%s", d.QuoteIdent(c), d.Placeholder(i+1))
+	}
+	return fmt.Sprintf(
+		`UPDATE %s SET %s WHERE %s=%s and %s=%s`,
+		d.QuoteIdent(table), strings.Join(sets, ", "),
+		d.QuoteIdent("group"), d.Placeholder(len(setColumns)+1),
+		d.QuoteIdent("version"), d.Placeholder(len(setColumns)+2),
+	)
+}
+
+func selectHistory(d Dialect, table string) string {
+	return fmt.Sprintf(`SELECT %s FROM %s`, quotedColumns(d, historyColumns), d.QuoteIdent(table))
+}
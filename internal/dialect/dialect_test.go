@@ -0,0 +1,76 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dialect
+
+import "testing"
+
+var builtins = []string{"postgresql", "mysql", "sqlite", "clickhouse", "sqlserver"}
+
+func TestLookupBuiltins(t *testing.T) {
+	for _, name := range builtins {
+		if _, ok := Lookup(name); !ok {
+			t.Errorf("expected built-in dialect %q to be registered", name)
+		}
+	}
+
+	if _, ok := Lookup("does-not-exist"); ok {
+		t.Errorf("Lookup of an unregistered name should report ok=false")
+	}
+}
+
+func TestRegisterDialectOverridesBuiltin(t *testing.T) {
+	custom := Postgres{}
+	RegisterDialect("mysql", custom)
+	defer RegisterDialect("mysql", MySQL{})
+
+	d, ok := Lookup("mysql")
+	if !ok {
+		t.Fatalf("expected mysql to still be registered after override")
+	}
+	if d.QuoteIdent("x") != custom.QuoteIdent("x") {
+		t.Errorf("RegisterDialect did not override the built-in mysql dialect")
+	}
+}
+
+// TestEveryBuiltinRendersUsableSQL checks that every built-in dialect renders
+// non-empty history statements and placeholders and quotes the reserved word
+// "group".
+func TestEveryBuiltinRendersUsableSQL(t *testing.T) {
+	const table = "migration_schema_history"
+	for _, name := range builtins {
+		d, _ := Lookup(name)
+
+		if stmt := d.CreateTable(table); stmt == "" {
+			t.Errorf("%s: CreateTable returned an empty statement", name)
+		}
+		if stmt := d.InsertHistory(table); stmt == "" {
+			t.Errorf("%s: InsertHistory returned an empty statement", name)
+		}
+		if stmt := d.UpdateHistory(table); stmt == "" {
+			t.Errorf("%s: UpdateHistory returned an empty statement", name)
+		}
+		if stmt := d.SelectHistory(table); stmt == "" {
+			t.Errorf("%s: SelectHistory returned an empty statement", name)
+		}
+		if ph := d.Placeholder(1); ph == "" {
+			t.Errorf("%s: Placeholder(1) returned an empty string", name)
+		}
+		if quoted := d.QuoteIdent("group"); quoted == "group" {
+			t.Errorf("%s: QuoteIdent did not quote a reserved word", name)
+		}
+	}
+}
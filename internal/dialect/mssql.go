@@ -0,0 +1,66 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dialect
+
+import "fmt"
+
+// SQLServer is the Dialect for Microsoft SQL Server.
+type SQLServer struct{}
+
+func (d SQLServer) CreateTable(table string) string {
+	return fmt.Sprintf(`IF NOT EXISTS (SELECT * FROM sysobjects WHERE name='%[2]s' AND xtype='U')
+CREATE TABLE %[1]s
+(
+    [group]              NVARCHAR(255)   NOT NULL,
+    [version]            BIGINT          NOT NULL,
+    [script]             NVARCHAR(255)   NOT NULL,
+    [type]               NVARCHAR(12)    NOT NULL,
+    [checksum]           CHAR(64)        NOT NULL,
+    [applied_at]         DATETIME2       NOT NULL,
+    [execution_duration] BIGINT          NOT NULL,
+    [status]             NVARCHAR(12)    NOT NULL,
+    [log]                NVARCHAR(MAX)   NOT NULL,
+    [down_script]        NVARCHAR(MAX)   NOT NULL DEFAULT '',
+    [down_checksum]      CHAR(64)        NOT NULL DEFAULT '',
+    PRIMARY KEY ([group], [version])
+)`, d.QuoteIdent(table), table)
+}
+
+func (d SQLServer) InsertHistory(table string) string { return insertHistory(d, table) }
+func (d SQLServer) UpdateHistory(table string) string { return updateHistory(d, table) }
+func (d SQLServer) SelectHistory(table string) string { return selectHistory(d, table) }
+
+func (d SQLServer) Placeholder(n int) string   { return fmt.Sprintf("@p%d", n) }
+func (d SQLServer) QuoteIdent(s string) string { return "[" + s + "]" }
+
+func (d SQLServer) IntrospectColumns(excludeTable string) string {
+	return fmt.Sprintf(`SELECT TABLE_NAME, COLUMN_NAME, DATA_TYPE, IS_NULLABLE
+FROM INFORMATION_SCHEMA.COLUMNS
+WHERE TABLE_NAME <> '%s'
+ORDER BY TABLE_NAME, ORDINAL_POSITION`, excludeTable)
+}
+
+func (d SQLServer) IntrospectIndexes(excludeTable string) string {
+	return fmt.Sprintf(`SELECT t.name AS table_name, i.name AS index_name, c.name AS column_name,
+       CASE WHEN i.is_unique = 1 THEN 'YES' ELSE 'NO' END AS is_unique
+FROM sys.indexes i
+JOIN sys.tables t ON t.object_id = i.object_id
+JOIN sys.index_columns ic ON ic.object_id = i.object_id AND ic.index_id = i.index_id
+JOIN sys.columns c ON c.object_id = ic.object_id AND c.column_id = ic.column_id
+WHERE i.name IS NOT NULL AND t.name <> '%s'
+ORDER BY t.name, i.name, ic.key_ordinal`, excludeTable)
+}
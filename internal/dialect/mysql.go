@@ -0,0 +1,64 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dialect
+
+import "fmt"
+
+// MySQL is the Dialect for MySQL and MariaDB.
+type MySQL struct{}
+
+const mysqlCreateTableTemplate = `CREATE TABLE IF NOT EXISTS %[1]s
+(
+    %[2]sgroup%[2]s              VARCHAR(255) NOT NULL,
+    %[2]sversion%[2]s            BIGINT       NOT NULL,
+    %[2]sscript%[2]s             VARCHAR(255) NOT NULL,
+    %[2]stype%[2]s               VARCHAR(12)  NOT NULL,
+    %[2]schecksum%[2]s           CHAR(64)     NOT NULL,
+    %[2]sapplied_at%[2]s         TIMESTAMP    NOT NULL,
+    %[2]sexecution_duration%[2]s BIGINT       NOT NULL,
+    %[2]sstatus%[2]s             VARCHAR(12)  NOT NULL,
+    %[2]slog%[2]s                TEXT         NOT NULL,
+    %[2]sdown_script%[2]s        TEXT         NOT NULL DEFAULT '',
+    %[2]sdown_checksum%[2]s      CHAR(64)     NOT NULL DEFAULT '',
+    PRIMARY KEY (%[2]sgroup%[2]s, %[2]sversion%[2]s)
+)`
+
+func (d MySQL) CreateTable(table string) string {
+	return fmt.Sprintf(mysqlCreateTableTemplate, d.QuoteIdent(table), "`")
+}
+
+func (d MySQL) InsertHistory(table string) string { return insertHistory(d, table) }
+func (d MySQL) UpdateHistory(table string) string { return updateHistory(d, table) }
+func (d MySQL) SelectHistory(table string) string { return selectHistory(d, table) }
+
+func (d MySQL) Placeholder(n int) string   { return "?" }
+func (d MySQL) QuoteIdent(s string) string { return "`" + s + "`" }
+
+func (d MySQL) IntrospectColumns(excludeTable string) string {
+	return fmt.Sprintf(`SELECT table_name, column_name, data_type, is_nullable
+FROM information_schema.columns
+WHERE table_schema = DATABASE() AND table_name <> '%s'
+ORDER BY table_name, ordinal_position`, excludeTable)
+}
+
+func (d MySQL) IntrospectIndexes(excludeTable string) string {
+	return fmt.Sprintf(`SELECT table_name, index_name, column_name,
+       CASE WHEN non_unique = 0 THEN 'YES' ELSE 'NO' END AS is_unique
+FROM information_schema.statistics
+WHERE table_schema = DATABASE() AND table_name <> '%s'
+ORDER BY table_name, index_name, seq_in_index`, excludeTable)
+}
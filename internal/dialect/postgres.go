@@ -0,0 +1,66 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dialect
+
+import "fmt"
+
+// Postgres is the Dialect for PostgreSQL.
+type Postgres struct{}
+
+func (d Postgres) CreateTable(table string) string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s
+(
+    "group"              VARCHAR(255) NOT NULL,
+    "version"            BIGINT       NOT NULL,
+    "script"             VARCHAR(255) NOT NULL,
+    "type"               VARCHAR(12)  NOT NULL,
+    "checksum"           CHAR(64)     NOT NULL,
+    "applied_at"         TIMESTAMP    NOT NULL,
+    "execution_duration" BIGINT       NOT NULL,
+    "status"             VARCHAR(12)  NOT NULL,
+    "log"                TEXT         NOT NULL,
+    "down_script"        TEXT         NOT NULL DEFAULT '',
+    "down_checksum"      CHAR(64)     NOT NULL DEFAULT '',
+    PRIMARY KEY ("group", "version")
+)`, d.QuoteIdent(table))
+}
+
+func (d Postgres) InsertHistory(table string) string { return insertHistory(d, table) }
+func (d Postgres) UpdateHistory(table string) string { return updateHistory(d, table) }
+func (d Postgres) SelectHistory(table string) string { return selectHistory(d, table) }
+
+func (d Postgres) Placeholder(n int) string   { return fmt.Sprintf("$%d", n) }
+func (d Postgres) QuoteIdent(s string) string { return `"` + s + `"` }
+
+func (d Postgres) IntrospectColumns(excludeTable string) string {
+	return fmt.Sprintf(`SELECT table_name, column_name, data_type, is_nullable
+FROM information_schema.columns
+WHERE table_schema = 'public' AND table_name <> '%s'
+ORDER BY table_name, ordinal_position`, excludeTable)
+}
+
+func (d Postgres) IntrospectIndexes(excludeTable string) string {
+	return fmt.Sprintf(`SELECT t.relname AS table_name, i.relname AS index_name, a.attname AS column_name,
+       CASE WHEN ix.indisunique THEN 'YES' ELSE 'NO' END AS is_unique
+FROM pg_index ix
+JOIN pg_class t ON t.oid = ix.indrelid
+JOIN pg_class i ON i.oid = ix.indexrelid
+JOIN pg_namespace n ON n.oid = t.relnamespace
+JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = ANY(ix.indkey)
+WHERE n.nspname = 'public' AND t.relname <> '%s'
+ORDER BY t.relname, i.relname, array_position(ix.indkey, a.attnum)`, excludeTable)
+}
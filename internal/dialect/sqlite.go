@@ -0,0 +1,73 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dialect
+
+import "fmt"
+
+// SQLite is the Dialect for SQLite. SQLite has no meaningful column type
+// constraints, but the declared types are kept for documentation purposes
+// and type affinity.
+type SQLite struct{}
+
+func (d SQLite) CreateTable(table string) string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s
+(
+    "group"              VARCHAR(255) NOT NULL,
+    "version"            BIGINT       NOT NULL,
+    "script"             VARCHAR(255) NOT NULL,
+    "type"               VARCHAR(12)  NOT NULL,
+    "checksum"           CHAR(64)     NOT NULL,
+    "applied_at"         TIMESTAMP    NOT NULL,
+    "execution_duration" BIGINT       NOT NULL,
+    "status"             VARCHAR(12)  NOT NULL,
+    "log"                TEXT         NOT NULL,
+    "down_script"        TEXT         NOT NULL DEFAULT '',
+    "down_checksum"      CHAR(64)     NOT NULL DEFAULT '',
+    PRIMARY KEY ("group", "version")
+)`, d.QuoteIdent(table))
+}
+
+func (d SQLite) InsertHistory(table string) string { return insertHistory(d, table) }
+func (d SQLite) UpdateHistory(table string) string { return updateHistory(d, table) }
+func (d SQLite) SelectHistory(table string) string { return selectHistory(d, table) }
+
+func (d SQLite) Placeholder(n int) string   { return "?" }
+func (d SQLite) QuoteIdent(s string) string { return `"` + s + `"` }
+
+// IntrospectColumns relies on the pragma_table_info table-valued function
+// (SQLite 3.16+), since SQLite has no information_schema.
+func (d SQLite) IntrospectColumns(excludeTable string) string {
+	return fmt.Sprintf(`SELECT m.name AS table_name, p.name AS column_name, p.type AS data_type,
+       CASE WHEN p."notnull" = 0 THEN 'YES' ELSE 'NO' END AS is_nullable
+FROM sqlite_master m
+JOIN pragma_table_info(m.name) p
+WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%%' AND m.name <> '%s'
+ORDER BY m.name, p.cid`, excludeTable)
+}
+
+// IntrospectIndexes relies on the pragma_index_list/pragma_index_info
+// table-valued functions (SQLite 3.16+), since SQLite has no
+// information_schema.
+func (d SQLite) IntrospectIndexes(excludeTable string) string {
+	return fmt.Sprintf(`SELECT m.name AS table_name, il.name AS index_name, ii.name AS column_name,
+       CASE WHEN il."unique" = 0 THEN 'NO' ELSE 'YES' END AS is_unique
+FROM sqlite_master m
+JOIN pragma_index_list(m.name) il
+JOIN pragma_index_info(il.name) ii
+WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%%' AND m.name <> '%s'
+ORDER BY m.name, il.name, ii.seqno`, excludeTable)
+}
@@ -0,0 +1,205 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sqlm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// lockTimeout bounds how long MustMigrate waits to acquire the
+// cross-process migration lock on PostgreSQL/MySQL before giving up.
+var lockTimeout = 30 * time.Second
+
+// SetLockTimeout overrides lockTimeout. It must be called before MustMigrate.
+func SetLockTimeout(d time.Duration) {
+	lockTimeout = d
+}
+
+// Locker coordinates migrations across processes - the in-process mutex
+// alone doesn't help when multiple replicas of a deployment boot
+// simultaneously, each in their own process. Lock must block until the lock
+// is held or ctx is done; Unlock releases it again.
+type Locker interface {
+	Lock(ctx context.Context) error
+	Unlock() error
+}
+
+// ApplyWithLock behaves like Apply, but first acquires locker and releases
+// it once Apply returns - successfully or not - so concurrent processes
+// serialize around the same migration run instead of racing each other.
+func ApplyWithLock(ctx context.Context, db DB, locker Locker, migrations ...Migration) error {
+	if err := locker.Lock(ctx); err != nil {
+		return fmt.Errorf("cannot acquire migration lock: %w", err)
+	}
+	defer func() {
+		if err := locker.Unlock(); err != nil {
+			log.Errorf("cannot release migration lock: %v", err)
+		}
+	}()
+
+	return Apply(db, migrations...)
+}
+
+// lockKey derives a stable 64-bit key for pg_advisory_lock from the
+// migration table name, so unrelated applications sharing a database don't
+// contend on each other's lock.
+func lockKey() int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(tableName))
+	return int64(h.Sum64())
+}
+
+// lockName derives a GET_LOCK name from lockKey, for the same reason: MySQL
+// locks are named strings rather than integers, but must key off the same
+// migration table name so unrelated applications sharing a database don't
+// contend on each other's lock either.
+func lockName() string {
+	return fmt.Sprintf("sqlm:%d", lockKey())
+}
+
+// PostgresLocker holds a pg_advisory_lock for the lifetime of a single
+// dedicated connection, so Lock and Unlock always talk to the same session -
+// advisory locks are released automatically if that session disconnects.
+type PostgresLocker struct {
+	db   *sql.DB
+	conn *sql.Conn
+}
+
+func NewPostgresLocker(db *sql.DB) *PostgresLocker {
+	return &PostgresLocker{db: db}
+}
+
+func (l *PostgresLocker) Lock(ctx context.Context) error {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey()); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	l.conn = conn
+	return nil
+}
+
+func (l *PostgresLocker) Unlock() error {
+	if l.conn == nil {
+		return nil
+	}
+	_, err := l.conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey())
+	closeErr := l.conn.Close()
+	l.conn = nil
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// MySQLLocker holds a named GET_LOCK for the lifetime of a single dedicated
+// connection, so Lock and Unlock always talk to the same session. Timeout
+// bounds how long Lock waits for a concurrent migration run to finish.
+type MySQLLocker struct {
+	db      *sql.DB
+	timeout time.Duration
+	conn    *sql.Conn
+}
+
+func NewMySQLLocker(db *sql.DB, timeout time.Duration) *MySQLLocker {
+	return &MySQLLocker{db: db, timeout: timeout}
+}
+
+func (l *MySQLLocker) Lock(ctx context.Context) error {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	var acquired sql.NullInt64
+	row := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName(), l.timeout.Seconds())
+	if err := row.Scan(&acquired); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	if acquired.Int64 != 1 {
+		_ = conn.Close()
+		return fmt.Errorf("timed out waiting for migration lock after %s", l.timeout)
+	}
+
+	l.conn = conn
+	return nil
+}
+
+func (l *MySQLLocker) Unlock() error {
+	if l.conn == nil {
+		return nil
+	}
+	_, err := l.conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName())
+	closeErr := l.conn.Close()
+	l.conn = nil
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// SQLiteLocker falls back to BEGIN EXCLUSIVE, since SQLite has no advisory
+// lock concept; it blocks every other writer against the same database file
+// for the duration of the migration run. It must be given its own *sql.DB,
+// separate from whatever connection/transaction Apply itself executes
+// migrations on, or the two will deadlock against each other.
+//
+// Lock does not wait for a lock held by another connection: BEGIN EXCLUSIVE
+// then fails with SQLite's busy error right away, unless the connection's
+// busy timeout makes SQLite itself retry.
+type SQLiteLocker struct {
+	db   *sql.DB
+	conn *sql.Conn
+}
+
+func NewSQLiteLocker(db *sql.DB) *SQLiteLocker {
+	return &SQLiteLocker{db: db}
+}
+
+func (l *SQLiteLocker) Lock(ctx context.Context) error {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, "BEGIN EXCLUSIVE"); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	l.conn = conn
+	return nil
+}
+
+func (l *SQLiteLocker) Unlock() error {
+	if l.conn == nil {
+		return nil
+	}
+	_, err := l.conn.ExecContext(context.Background(), "COMMIT")
+	closeErr := l.conn.Close()
+	l.conn = nil
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
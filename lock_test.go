@@ -0,0 +1,85 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sqlm_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/worldiety/sqlm"
+)
+
+// openSQLiteFile opens path (shared by every *sql.DB opened with the same
+// path) rather than an in-memory database, since SQLiteLocker's contention
+// only shows up across separate connections to the same file.
+func openSQLiteFile(t *testing.T, path string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("cannot open sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestSQLiteLockerBlocksConcurrentLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock.db")
+	first := sqlm.NewSQLiteLocker(openSQLiteFile(t, path))
+	second := sqlm.NewSQLiteLocker(openSQLiteFile(t, path))
+
+	if err := first.Lock(context.Background()); err != nil {
+		t.Fatalf("first Lock failed: %v", err)
+	}
+
+	if err := second.Lock(context.Background()); err == nil {
+		t.Fatal("expected second Lock to fail while first holds the lock")
+	}
+
+	if err := first.Unlock(); err != nil {
+		t.Fatalf("first Unlock failed: %v", err)
+	}
+
+	if err := second.Lock(context.Background()); err != nil {
+		t.Fatalf("expected second Lock to succeed once first released it, got: %v", err)
+	}
+	if err := second.Unlock(); err != nil {
+		t.Fatalf("second Unlock failed: %v", err)
+	}
+}
+
+func TestSQLiteLockerLockRespectsCanceledContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock.db")
+	locker := sqlm.NewSQLiteLocker(openSQLiteFile(t, path))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := locker.Lock(ctx); err == nil {
+		t.Fatal("expected Lock to fail against an already-canceled context")
+	}
+}
+
+func TestSQLiteLockerUnlockWithoutLockIsNoop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock.db")
+	locker := sqlm.NewSQLiteLocker(openSQLiteFile(t, path))
+
+	if err := locker.Unlock(); err != nil {
+		t.Fatalf("expected Unlock without a held lock to be a no-op, got: %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sqlm
+
+// Logger receives sqlm's internal diagnostics, so that consumers can route
+// them into their own logging/metrics/tracing stack instead of sqlm writing
+// straight to stdout. SetLogger installs one; the default is a no-op.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+var log Logger = noopLogger{}
+
+// SetLogger installs l as the Logger used by scan, parseMigrations,
+// MustMigrate and friends. Passing nil restores the no-op default.
+func SetLogger(l Logger) {
+	if l == nil {
+		l = noopLogger{}
+	}
+	log = l
+}
+
+type noopLogger struct{}
+
+func (noopLogger) Debugf(format string, args ...interface{}) {}
+func (noopLogger) Infof(format string, args ...interface{})  {}
+func (noopLogger) Warnf(format string, args ...interface{})  {}
+func (noopLogger) Errorf(format string, args ...interface{}) {}
@@ -26,21 +26,29 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/worldiety/sqlm/internal/dialect"
 )
 
-const createMigrationTable = `CREATE TABLE IF NOT EXISTS "migration_schema_history"
-(
-    "group"              VARCHAR(255) NOT NULL,
-    "version"            BIGINT       NOT NULL,
-    "script"             VARCHAR(255) NOT NULL,
-    "type"               VARCHAR(12)  NOT NULL,
-    "checksum"           CHAR(64)     NOT NULL,
-    "applied_at"         TIMESTAMP    NOT NULL,
-    "execution_duration" BIGINT       NOT NULL,
-    "status"             VARCHAR(12)  NOT NULL,
-    "log"                TEXT         NOT NULL,
-    PRIMARY KEY ("group", "version")
-)`
+// tableName is the name of the migration bookkeeping table. It defaults to
+// "migration_schema_history" for backwards compatibility, but can be changed
+// with SetTableName so that multiple applications can share a database
+// without colliding.
+var tableName = "migration_schema_history"
+
+// SetTableName overrides the name of the migration bookkeeping table. It
+// must be called before Apply/MustMigrate/History/Rollback and is not safe
+// to change once migrations have been recorded under the previous name.
+func SetTableName(name string) {
+	tableName = name
+}
+
+// RegisterDialect makes a custom dialect.Dialect available under name so
+// that version() and Apply can target database engines sqlm doesn't ship
+// support for out of the box.
+func RegisterDialect(name string, d dialect.Dialect) {
+	dialect.RegisterDialect(name, d)
+}
 
 type Type string
 type Status string
@@ -52,12 +60,16 @@ var mutex sync.Mutex
 
 const (
 	SQL        Type   = "sql"
+	Go         Type   = "go"
 	Success    Status = "success"
 	Failed     Status = "failed"
 	Pending    Status = "pending"
 	Executing  Status = "executing"
 	PostgreSQL DBType = "postgresql"
 	MySQL      DBType = "mysql"
+	SQLite     DBType = "sqlite"
+	ClickHouse DBType = "clickhouse"
+	SQLServer  DBType = "sqlserver"
 )
 
 type HistoryEntry struct {
@@ -70,16 +82,45 @@ type HistoryEntry struct {
 	ExecutionDuration time.Duration
 	Status            Status
 	Log               string
+	DownScript        string
+	DownChecksum      string
 }
 
 type Migration = struct {
-	Group      string
-	Version    int64
-	Statements []string
-	ScriptName string
+	Group          string
+	Version        int64
+	Statements     []string
+	ScriptName     string
+	DownStatements []string
+	Type           Type
+	GoUp           func(ctx context.Context, tx *sql.Tx) error
+	GoDown         func(ctx context.Context, tx *sql.Tx) error
+}
+
+// migrationType returns the effective type of m, defaulting to SQL for
+// migrations built before Type existed (e.g. constructed directly by
+// callers, or produced by scan()/ScanFS()).
+func migrationType(m Migration) Type {
+	if m.Type == "" {
+		return SQL
+	}
+	return m.Type
+}
+
+func downHash(m Migration) string {
+	sum := sha256.Sum256([]byte(strings.Join(m.DownStatements, ";")))
+	return hex.EncodeToString(sum[:])
 }
 
 func hash(m Migration) string {
+	if migrationType(m) == Go {
+		// Go migrations are identified by code, not SQL text, so compiling
+		// the binary again must not dirty their history. The checksum is
+		// derived from the migration's own name and version instead of its
+		// (non-existent) source.
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%d:%s", m.Group, m.Version, m.ScriptName)))
+		return hex.EncodeToString(sum[:])
+	}
 	sum := sha256.Sum256([]byte(strings.Join(m.Statements, ";")))
 	return hex.EncodeToString(sum[:])
 }
@@ -91,14 +132,61 @@ type DB interface {
 
 // MustMigrate panics, if the migrations cannot be applied.
 // Creates a transaction and tries a rollback, before bailing out.
+//
+// On PostgreSQL and MySQL, MustMigrate additionally waits up to lockTimeout
+// (see SetLockTimeout) to acquire a cross-process lock, so that several
+// replicas of the same application booting concurrently serialize around the
+// same migration run instead of racing each other; the in-process mutex
+// alone only protects against concurrent goroutines within this process. No
+// such lock is available for other dialects, so they keep racing as before -
+// use ApplyWithLock directly with a Locker of your own if you need it there.
+// Unlike ApplyWithLock, which only wraps Apply, MustMigrate holds the lock
+// across both the migration transaction and its commit, and only releases it
+// once the commit has actually landed - otherwise a second replica could
+// acquire the lock and start applying migrations again before the first
+// replica's history is durable.
+//
+// The lock is held on its own *sql.Conn from db, separate from the one the
+// migration transaction itself runs on, so db must be able to hand out at
+// least two connections at once. db.SetMaxOpenConns(1) - a common choice for
+// a dedicated migration runner, and exactly the "replicas booting
+// simultaneously" scenario the lock targets - lets Lock() take the pool's only
+// connection, after which BeginTx waits for a free connection with no deadline
+// and MustMigrate never returns. Leave the pool's default (unlimited) connection count, or set
+// it to at least 2, when calling MustMigrate against PostgreSQL or MySQL.
 func MustMigrate(db *sql.DB, migrations ...Migration) {
+	ctx, cancel := context.WithTimeout(context.Background(), lockTimeout)
+	defer cancel()
+
+	var locker Locker
+	if dbType, err := version(db); err == nil {
+		switch dbType {
+		case PostgreSQL:
+			locker = NewPostgresLocker(db)
+		case MySQL:
+			locker = NewMySQLLocker(db, lockTimeout)
+		}
+	}
+
+	if locker != nil {
+		if err := locker.Lock(ctx); err != nil {
+			panic(fmt.Errorf("cannot acquire migration lock: %w", err))
+		}
+		defer func() {
+			if err := locker.Unlock(); err != nil {
+				log.Errorf("cannot release migration lock: %v", err)
+			}
+		}()
+	}
+
 	tx, err := db.BeginTx(context.Background(), nil)
 	if err != nil {
 		panic(err)
 	}
+
 	if err := Apply(tx, migrations...); err != nil {
 		if suppressedErr := tx.Rollback(); suppressedErr != nil {
-			fmt.Println(suppressedErr.Error())
+			log.Errorf("rollback after failed migration also failed: %v", suppressedErr)
 		}
 		panic(err)
 	}
@@ -107,7 +195,28 @@ func MustMigrate(db *sql.DB, migrations ...Migration) {
 	}
 }
 
+// Hooks are optional callbacks invoked around each migration's execution,
+// letting consumers wire up metrics, tracing spans or failure alerts without
+// forking the module. Any callback may be left nil.
+type Hooks struct {
+	BeforeApply func(m Migration)
+	AfterApply  func(m Migration, entry HistoryEntry)
+	OnFailure   func(m Migration, err error)
+}
+
+// ApplyOptions configures an Apply run. The zero value behaves exactly like
+// calling Apply directly: no hooks are invoked.
+type ApplyOptions struct {
+	Hooks Hooks
+}
+
 func Apply(db DB, migrations ...Migration) error {
+	return ApplyWithOptions(db, ApplyOptions{}, migrations...)
+}
+
+// ApplyWithOptions behaves like Apply, but additionally invokes opts.Hooks
+// around each migration's execution.
+func ApplyWithOptions(db DB, opts ApplyOptions, migrations ...Migration) error {
 	mutex.Lock()
 	defer mutex.Unlock()
 
@@ -116,11 +225,11 @@ func Apply(db DB, migrations ...Migration) error {
 		return fmt.Errorf("unknown database type: %w", err)
 	}
 
-	if err := CreateTable(db); err != nil {
+	if err := CreateTable(dbType, db); err != nil {
 		return fmt.Errorf("cannot create migration table: %w", err)
 	}
 
-	entries, err := History(db)
+	entries, err := History(dbType, db)
 	if err != nil {
 		return fmt.Errorf("cannot get history: %w", err)
 	}
@@ -142,7 +251,7 @@ func Apply(db DB, migrations ...Migration) error {
 						return fmt.Errorf("an already applied migration has been modified. Needs manual fix: %v vs %v", entry, migration)
 					}
 					alreadyApplied = true
-					//fmt.Printf("migration already applied: %s.%d\n", migration.Group, migration.Version)
+					log.Debugf("migration already applied: %s.%d", migration.Group, migration.Version)
 					break
 				}
 			}
@@ -175,11 +284,17 @@ func Apply(db DB, migrations ...Migration) error {
 				Group:             migration.Group,
 				Version:           migration.Version,
 				Script:            migration.ScriptName,
-				Type:              SQL,
+				Type:              migrationType(migration),
 				Checksum:          hash(migration),
 				AppliedAt:         time.Now(),
 				ExecutionDuration: 0,
 				Status:            Executing,
+				DownScript:        strings.Join(migration.DownStatements, ";"),
+				DownChecksum:      downHash(migration),
+			}
+
+			if opts.Hooks.BeforeApply != nil {
+				opts.Hooks.BeforeApply(migration)
 			}
 
 			start := time.Now()
@@ -191,6 +306,9 @@ func Apply(db DB, migrations ...Migration) error {
 				entry.Log = err.Error()
 				entry.Status = Failed
 				_ = update(dbType, db, entry)
+				if opts.Hooks.OnFailure != nil {
+					opts.Hooks.OnFailure(migration, err)
+				}
 				return fmt.Errorf("failed to execute migration %s.%d: %w", migration.Group, migration.Version, err)
 			}
 
@@ -200,70 +318,104 @@ func Apply(db DB, migrations ...Migration) error {
 			if err := update(dbType, db, entry); err != nil {
 				return fmt.Errorf("failed to update history migration: %w", err)
 			}
+
+			if opts.Hooks.AfterApply != nil {
+				opts.Hooks.AfterApply(migration, entry)
+			}
 		}
 	}
 	return nil
 }
 
-func version(tx DB) (DBType, error) {
-	rows, err := tx.Query("SELECT version()")
+// queryString runs query and returns the single string column of its last
+// row, used by version() to probe a connection with a handful of
+// dialect-specific "what are you" queries.
+func queryString(tx DB, query string) (string, error) {
+	rows, err := tx.Query(query)
 	if err != nil {
 		return "", err
 	}
+	defer rows.Close()
 
-	// e.g. PostgreSQL 12.2 on x86_64-apple-darwin19.4.0, compiled by Apple clang version 11.0.3 (clang-1103.0.32.59), 64-bit
-	// e.g. 10.4.11-MariaDB
 	var str string
 	for rows.Next() {
 		if err := rows.Scan(&str); err != nil {
 			return "", err
 		}
 	}
-	str = strings.ToLower(str)
-	if strings.Contains(str, "postgresql") {
-		return PostgreSQL, nil
+	if rows.Err() != nil {
+		return "", rows.Err()
+	}
+	return str, nil
+}
+
+func version(tx DB) (DBType, error) {
+	// e.g. PostgreSQL 12.2 on x86_64-apple-darwin19.4.0, compiled by Apple clang version 11.0.3 (clang-1103.0.32.59), 64-bit
+	// e.g. 10.4.11-MariaDB
+	// e.g. 21.8.3.44 (ClickHouse server version)
+	if str, err := queryString(tx, "SELECT version()"); err == nil {
+		str = strings.ToLower(str)
+		switch {
+		case strings.Contains(str, "postgresql"):
+			return PostgreSQL, nil
+		case strings.Contains(str, "mariadb"), strings.Contains(str, "mysql"):
+			return MySQL, nil
+		case strings.Contains(str, "clickhouse"):
+			return ClickHouse, nil
+		}
 	}
 
-	if strings.Contains(str, "mariadb") {
-		return MySQL, nil
+	// SQLite and SQL Server don't implement SELECT version(), so they are
+	// detected with their own probe query instead.
+	if _, err := queryString(tx, "SELECT sqlite_version()"); err == nil {
+		return SQLite, nil
 	}
 
-	if strings.Contains(str, "mysql") {
-		return MySQL, nil
+	if _, err := queryString(tx, "SELECT @@VERSION"); err == nil {
+		return SQLServer, nil
 	}
 
-	return "", fmt.Errorf("unknown database type: %s", str)
+	return "", fmt.Errorf("unable to detect database type")
+}
+
+func lookupDialect(dbtype DBType) (dialect.Dialect, error) {
+	d, ok := dialect.Lookup(string(dbtype))
+	if !ok {
+		return nil, fmt.Errorf("no dialect registered for database type %q", dbtype)
+	}
+	return d, nil
 }
 
 func insert(dbtype DBType, tx DB, entry HistoryEntry) error {
-	var stmt string
-	switch dbtype {
-	case MySQL:
-		stmt = `INSERT INTO "migration_schema_history" ("group", "version", "script", "type", "checksum", "applied_at", "execution_duration", "status", "log") VALUES (?,?,?,?,?,?,?,?,?)`
-	case PostgreSQL:
-		stmt = `INSERT INTO "migration_schema_history" ("group", "version", "script", "type", "checksum", "applied_at", "execution_duration", "status", "log") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)`
-	}
-	if _, err := tx.Exec(stmt, entry.Group, entry.Version, entry.Script, entry.Type, entry.Checksum, entry.AppliedAt, entry.ExecutionDuration, entry.Status, entry.Log); err != nil {
+	d, err := lookupDialect(dbtype)
+	if err != nil {
 		return err
 	}
-	return nil
+	_, err = tx.Exec(d.InsertHistory(tableName), entry.Group, entry.Version, entry.Script, entry.Type, entry.Checksum, entry.AppliedAt, entry.ExecutionDuration, entry.Status, entry.Log, entry.DownScript, entry.DownChecksum)
+	return err
 }
 
 func update(dbtype DBType, tx DB, entry HistoryEntry) error {
-	var stmt string
-	switch dbtype {
-	case MySQL:
-		stmt = `UPDATE "migration_schema_history" SET "script"=?, "type"=?, "checksum"=?, "applied_at"=?, "execution_duration"=?, "status"=?, "log"=? WHERE "group"=? and "version"=?`
-	case PostgreSQL:
-		stmt = `UPDATE migration_schema_history SET "script"=$1, "type"=$2, "checksum"=$3, "applied_at"=$4, "execution_duration"=$5, "status"=$6, log=$7 WHERE "group"=$8 and "version"=$9`
-	}
-	if _, err := tx.Exec(stmt, entry.Script, entry.Type, entry.Checksum, entry.AppliedAt, entry.ExecutionDuration, entry.Status, entry.Log, entry.Group, entry.Version); err != nil {
+	d, err := lookupDialect(dbtype)
+	if err != nil {
 		return err
 	}
-	return nil
+	_, err = tx.Exec(d.UpdateHistory(tableName), entry.Script, entry.Type, entry.Checksum, entry.AppliedAt, entry.ExecutionDuration, entry.Status, entry.Log, entry.DownScript, entry.DownChecksum, entry.Group, entry.Version)
+	return err
 }
 
 func execute(tx DB, migration Migration) error {
+	if migrationType(migration) == Go {
+		if migration.GoUp == nil {
+			return fmt.Errorf("go migration %s.%d has no up function registered", migration.Group, migration.Version)
+		}
+		sqlTx, ok := tx.(*sql.Tx)
+		if !ok {
+			return fmt.Errorf("go migration %s.%d requires a *sql.Tx, got %T", migration.Group, migration.Version, tx)
+		}
+		return migration.GoUp(context.Background(), sqlTx)
+	}
+
 	for _, stmt := range migration.Statements {
 		if _, err := tx.Exec(stmt); err != nil {
 			return fmt.Errorf("failed to execute statement '%s': %w", stmt, err)
@@ -272,13 +424,48 @@ func execute(tx DB, migration Migration) error {
 	return nil
 }
 
-func CreateTable(tx DB) error {
-	_, err := tx.Exec(createMigrationTable)
-	return err
+func CreateTable(dbtype DBType, tx DB) error {
+	d, err := lookupDialect(dbtype)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(d.CreateTable(tableName)); err != nil {
+		return err
+	}
+
+	// the table may already exist from before down migrations were
+	// introduced, so make sure the new columns are present too. Failures
+	// (column already exists) are expected on every subsequent call and are
+	// silently ignored.
+	for _, stmt := range downColumnMigration[dbtype] {
+		_, _ = tx.Exec(fmt.Sprintf(stmt, d.QuoteIdent(tableName)))
+	}
+
+	return nil
+}
+
+// downColumnMigration holds the self-applied ALTER TABLE statements that add
+// the down_script/down_checksum columns to a migration_schema_history table
+// created before reversible migrations existed. %s is replaced with the
+// dialect-quoted table name.
+var downColumnMigration = map[DBType][]string{
+	PostgreSQL: {
+		`ALTER TABLE %s ADD COLUMN IF NOT EXISTS "down_script" TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE %s ADD COLUMN IF NOT EXISTS "down_checksum" CHAR(64) NOT NULL DEFAULT ''`,
+	},
+	MySQL: {
+		"ALTER TABLE %s ADD COLUMN `down_script` TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE %s ADD COLUMN `down_checksum` CHAR(64) NOT NULL DEFAULT ''",
+	},
 }
 
-func History(tx DB) ([]HistoryEntry, error) {
-	rows, err := tx.Query(`SELECT "group", "version", "script", "type", "checksum", "applied_at", "execution_duration", "status","log" FROM "migration_schema_history"`)
+func History(dbtype DBType, tx DB) ([]HistoryEntry, error) {
+	d, err := lookupDialect(dbtype)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(d.SelectHistory(tableName))
 	if err != nil {
 		return nil, fmt.Errorf("cannot select history: %w", err)
 	}
@@ -287,7 +474,7 @@ func History(tx DB) ([]HistoryEntry, error) {
 	var res []HistoryEntry
 	for rows.Next() {
 		entry := HistoryEntry{}
-		err = rows.Scan(&entry.Group, &entry.Version, &entry.Script, &entry.Type, &entry.Checksum, &entry.AppliedAt, &entry.ExecutionDuration, &entry.Status, &entry.Log)
+		err = rows.Scan(&entry.Group, &entry.Version, &entry.Script, &entry.Type, &entry.Checksum, &entry.AppliedAt, &entry.ExecutionDuration, &entry.Status, &entry.Log, &entry.DownScript, &entry.DownChecksum)
 		if err != nil {
 			return res, fmt.Errorf("cannot scan entry: %w", err)
 		}
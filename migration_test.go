@@ -0,0 +1,45 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sqlm_test
+
+import (
+	"testing"
+
+	"github.com/worldiety/sqlm"
+)
+
+func TestSetTableNameMovesBookkeepingTable(t *testing.T) {
+	sqlm.SetTableName("custom_schema_history")
+	t.Cleanup(func() { sqlm.SetTableName("migration_schema_history") })
+
+	db := openSQLite(t)
+	m := sqlm.Migration{
+		Group:      "widgets",
+		Version:    1,
+		Statements: []string{"CREATE TABLE widgets (id INTEGER PRIMARY KEY)"},
+	}
+	if err := sqlm.Apply(db, m); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	if !tableExists(t, db, "custom_schema_history") {
+		t.Error("expected the bookkeeping table to be created under the custom name")
+	}
+	if tableExists(t, db, "migration_schema_history") {
+		t.Error("did not expect the default bookkeeping table name to be used")
+	}
+}
@@ -22,10 +22,51 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
 
+var migrateUpMarker = regexp.MustCompile(`(?im)^--\s*\+migrate\s+Up\s*$`)
+var migrateDownMarker = regexp.MustCompile(`(?im)^--\s*\+migrate\s+Down\s*$`)
+
+// commentsRegex strips "--" line comments before a migration file's
+// statements are split on ";", so a commented-out statement terminator
+// doesn't get mistaken for a real one.
+var commentsRegex = regexp.MustCompile(`(?m)--[^\n]*`)
+
+// packageConfig is a single entry of a sqlm.json manifest's "packages" list,
+// naming one migration group and the directory its SQL files live in.
+type packageConfig struct {
+	Group  string `json:"group"`
+	Schema string `json:"schema"`
+}
+
+// schemaConfig is the decoded content of a sqlm.json manifest.
+type schemaConfig struct {
+	Packages []packageConfig `json:"packages"`
+}
+
+// migrationGroup is a single sqlm.json manifest discovered by scan(), together
+// with the packages it describes.
+type migrationGroup struct {
+	file     string
+	config   schemaConfig
+	packages []schemaPackage
+}
+
+// schemaPackage holds the migrations collected for a single packageConfig
+// entry of a sqlm.json manifest.
+type schemaPackage struct {
+	pkg        packageConfig
+	migrations []Migration
+}
+
+// scan walks dir for sqlm.json manifests and parses the migrations each one
+// describes. It predates ScanFS/LoadFS (see fs.go) and is kept for callers
+// that still lay out their migrations on a writable filesystem rather than
+// embedding them.
 func scan(dir string) ([]migrationGroup, error) {
 	var res []migrationGroup
 	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
@@ -43,60 +84,127 @@ func scan(dir string) ([]migrationGroup, error) {
 			if err != nil {
 				return err
 			}
-			dialects, err := parseMigrations(group)
+			packages, err := parseMigrations(group)
 			if err != nil {
 				return fmt.Errorf("failed to parse migrations: %w", err)
 			}
-			group.dialects = dialects
+			group.packages = packages
 			res = append(res, group)
-			//fmt.Printf("found %v\n", group)
+			log.Debugf("found migration group %s", path)
 		}
 		return nil
 	})
 	return res, err
 }
 
-func parseMigrations(cfg migrationGroup) ([]dialect, error) {
+// parseMigrations reads every package in cfg off the OS filesystem. It still
+// calls ioutil.ReadDir/ioutil.ReadFile directly rather than taking an fs.FS,
+// since os.DirFS doesn't preserve the directory walking scan() already did to
+// find cfg in the first place; the fs.FS-friendly logic lives in
+// collectMigrations instead, shared as-is by both this legacy path and
+// ScanFS/LoadFS (see fs.go) via an injected readFile closure.
+func parseMigrations(cfg migrationGroup) ([]schemaPackage, error) {
 	dir := filepath.Dir(cfg.file)
-	var res []dialect
+	var res []schemaPackage
 	for _, pkg := range cfg.config.Packages {
-		dlc := dialect{pkg: pkg}
+		dlc := schemaPackage{pkg: pkg}
 		schemaDir := filepath.Join(dir, pkg.Schema)
-		fmt.Printf("reading schema dir %s\n", schemaDir)
+		log.Debugf("reading schema dir %s", schemaDir)
 		files, err := ioutil.ReadDir(schemaDir)
 		if err != nil {
 			return nil, err
 		}
+
+		var names []string
 		for _, file := range files {
-			if strings.HasSuffix(file.Name(), ".sql") {
-				version := extractVersion(file.Name())
-				if version == -1 {
-					return nil, fmt.Errorf("invalid migration file name: %s", file.Name())
-				}
-				fmt.Printf("migration %s\n", file.Name())
-				fname := filepath.Join(schemaDir, file.Name())
-				stmts, err := parseStatements(fname)
-				if err != nil {
-					return nil, err
-				}
-				if len(stmts) == 0 {
-					return nil, fmt.Errorf("migration file without statements: %s", fname)
-				}
-				fmt.Printf("   %d statements\n", len(stmts))
-				migration := Migration{
-					Group:      pkg.Group,
-					Version:    version,
-					Statements: stmts,
-					ScriptName: file.Name(),
-				}
-				dlc.migrations = append(dlc.migrations, migration)
-			}
+			names = append(names, file.Name())
 		}
+
+		migrations, err := collectMigrations(pkg.Group, names, func(name string) ([]byte, error) {
+			return ioutil.ReadFile(filepath.Join(schemaDir, name))
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		dlc.migrations = migrations
 		res = append(res, dlc)
 	}
 	return res, nil
 }
 
+// collectMigrations groups fileNames belonging to group into Migrations,
+// ordered by version. A reversible migration may be split across a pair of
+// <version>_up.sql / <version>_down.sql files that both resolve to the same
+// version, or combine up/down into a single file using "-- +migrate Up" /
+// "-- +migrate Down" markers; both conventions are accepted here. readFile
+// is injected so the same logic works against the OS filesystem and an
+// fs.FS alike.
+func collectMigrations(group string, fileNames []string, readFile func(name string) ([]byte, error)) ([]Migration, error) {
+	byVersion := make(map[int64]*Migration)
+	var order []int64
+	for _, name := range fileNames {
+		if !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+		version := extractVersion(name)
+		if version == -1 {
+			return nil, fmt.Errorf("invalid migration file name: %s", name)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Group: group, Version: version}
+			byVersion[version] = m
+			order = append(order, version)
+		}
+
+		b, err := readFile(name)
+		if err != nil {
+			return nil, err
+		}
+		content := string(b)
+
+		lowerName := strings.ToLower(name)
+		switch {
+		case strings.Contains(lowerName, "_down."):
+			stmts, err := parseStatementsFromString(content)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse %s: %w", name, err)
+			}
+			m.DownStatements = stmts
+		case strings.Contains(lowerName, "_up."):
+			stmts, err := parseStatementsFromString(content)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse %s: %w", name, err)
+			}
+			if len(stmts) == 0 {
+				return nil, fmt.Errorf("migration file without statements: %s", name)
+			}
+			m.Statements = stmts
+			m.ScriptName = name
+		default:
+			up, down, err := splitUpDownStatements(content, name)
+			if err != nil {
+				return nil, err
+			}
+			if len(up) == 0 {
+				return nil, fmt.Errorf("migration file without statements: %s", name)
+			}
+			m.Statements = up
+			m.DownStatements = down
+			m.ScriptName = name
+		}
+	}
+
+	sort.Slice(order, func(i, j int) bool { return order[i] < order[j] })
+	res := make([]Migration, 0, len(order))
+	for _, v := range order {
+		res = append(res, *byVersion[v])
+	}
+	return res, nil
+}
+
 func extractVersion(name string) int64 {
 	sb := &strings.Builder{}
 	for _, r := range name {
@@ -114,17 +222,37 @@ func extractVersion(name string) int64 {
 	return i
 }
 
-func parseStatements(fname string) ([]string, error) {
-	b, err := ioutil.ReadFile(fname)
-	if err != nil {
-		return nil, err
+// splitUpDownStatements splits the content of a single migration file into
+// its up and down statements using "-- +migrate Up" / "-- +migrate Down"
+// section markers, in the style of goose/sql-migrate. Content without a
+// Down marker is treated as an up-only migration; an Up marker is optional
+// if the file has no preamble. name is only used for error messages.
+func splitUpDownStatements(content string, name string) ([]string, []string, error) {
+	downLoc := migrateDownMarker.FindStringIndex(content)
+	if downLoc == nil {
+		up, err := parseStatementsFromString(stripUpMarker(content))
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot parse %s: %w", name, err)
+		}
+		return up, nil, nil
 	}
 
-	stmts, err := parseStatementsFromString(string(b))
+	up, err := parseStatementsFromString(stripUpMarker(content[:downLoc[0]]))
 	if err != nil {
-		return nil, fmt.Errorf("cannot parse %s: %w", fname, err)
+		return nil, nil, fmt.Errorf("cannot parse up section of %s: %w", name, err)
 	}
-	return stmts, nil
+	down, err := parseStatementsFromString(content[downLoc[1]:])
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot parse down section of %s: %w", name, err)
+	}
+	return up, down, nil
+}
+
+func stripUpMarker(str string) string {
+	if loc := migrateUpMarker.FindStringIndex(str); loc != nil {
+		return str[loc[1]:]
+	}
+	return str
 }
 
 func parseStatementsFromString(str string) ([]string, error) {
@@ -0,0 +1,150 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sqlm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Rollback undoes already applied migrations of the given group, in reverse
+// version order, down to and excluding targetVersion. The down statements are
+// the ones recorded in migration_schema_history at apply time, not whatever
+// currently lives in the source tree, so a rollback always undoes exactly
+// what was actually applied.
+//
+// Before executing a down script, its checksum is compared against the one
+// recorded for the matching up migration. If they differ - e.g. because the
+// history was edited by hand - Rollback aborts before running that down
+// script; migrations already rolled back earlier in the same call stay rolled
+// back.
+//
+// Go migrations (see RegisterGo) can't be persisted, so their down function
+// isn't in migration_schema_history like a SQL down script is. To roll one
+// back, pass the same Migration RegisterGo returned for it in migrations;
+// Rollback matches it to its history entry by group and version and invokes
+// its GoDown. In that case db must be a *sql.Tx, the same requirement Apply
+// has for Go migrations.
+func Rollback(db DB, targetVersion int64, group string, migrations ...Migration) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	dbType, err := version(db)
+	if err != nil {
+		return fmt.Errorf("unknown database type: %w", err)
+	}
+
+	entries, err := History(dbType, db)
+	if err != nil {
+		return fmt.Errorf("cannot get history: %w", err)
+	}
+
+	goMigrations := make(map[int64]Migration)
+	for _, m := range migrations {
+		if m.Group == group && migrationType(m) == Go {
+			goMigrations[m.Version] = m
+		}
+	}
+
+	var candidates []HistoryEntry
+	for _, entry := range entries {
+		if entry.Group != group {
+			continue
+		}
+		if entry.Status != Success {
+			return fmt.Errorf("migrations are dirty. Needs manual fix: %+v", entry)
+		}
+		if entry.Version > targetVersion {
+			candidates = append(candidates, entry)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Version > candidates[j].Version
+	})
+
+	for _, entry := range candidates {
+		if entry.Type == Go {
+			m, ok := goMigrations[entry.Version]
+			if !ok || m.GoDown == nil {
+				return fmt.Errorf("migration %s.%d is a Go migration with no down function; pass its RegisterGo-returned Migration to Rollback so it can find GoDown", entry.Group, entry.Version)
+			}
+
+			sqlTx, ok := db.(*sql.Tx)
+			if !ok {
+				return fmt.Errorf("go migration %s.%d requires a *sql.Tx, got %T", entry.Group, entry.Version, db)
+			}
+			if err := m.GoDown(context.Background(), sqlTx); err != nil {
+				return fmt.Errorf("failed to execute down function of migration %s.%d: %w", entry.Group, entry.Version, err)
+			}
+			if err := deleteHistory(dbType, db, entry.Group, entry.Version); err != nil {
+				return fmt.Errorf("failed to delete history entry %s.%d: %w", entry.Group, entry.Version, err)
+			}
+			continue
+		}
+
+		downStmts := splitDownScript(entry.DownScript)
+		if len(downStmts) == 0 {
+			return fmt.Errorf("migration %s.%d has no recorded down script and cannot be rolled back automatically. Needs manual fix: %+v", entry.Group, entry.Version, entry)
+		}
+		if downHash(Migration{DownStatements: downStmts}) != entry.DownChecksum {
+			return fmt.Errorf("the recorded down script has drifted from its checksum. Needs manual fix: %+v", entry)
+		}
+
+		for _, stmt := range downStmts {
+			if _, err := db.Exec(stmt); err != nil {
+				return fmt.Errorf("failed to execute down statement '%s' of migration %s.%d: %w", stmt, entry.Group, entry.Version, err)
+			}
+		}
+
+		if err := deleteHistory(dbType, db, entry.Group, entry.Version); err != nil {
+			return fmt.Errorf("failed to delete history entry %s.%d: %w", entry.Group, entry.Version, err)
+		}
+	}
+
+	return nil
+}
+
+func splitDownScript(script string) []string {
+	if strings.TrimSpace(script) == "" {
+		return nil
+	}
+	parts := strings.Split(script, ";")
+	var stmts []string
+	for _, p := range parts {
+		if t := strings.TrimSpace(p); t != "" {
+			stmts = append(stmts, t)
+		}
+	}
+	return stmts
+}
+
+func deleteHistory(dbtype DBType, tx DB, group string, version int64) error {
+	d, err := lookupDialect(dbtype)
+	if err != nil {
+		return err
+	}
+	stmt := fmt.Sprintf(
+		`DELETE FROM %s WHERE %s=%s and %s=%s`,
+		d.QuoteIdent(tableName), d.QuoteIdent("group"), d.Placeholder(1), d.QuoteIdent("version"), d.Placeholder(2),
+	)
+	_, err = tx.Exec(stmt, group, version)
+	return err
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sqlm_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/worldiety/sqlm"
+)
+
+func openSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("cannot open sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+	var got string
+	err := db.QueryRow(`SELECT name FROM sqlite_master WHERE type='table' AND name=?`, name).Scan(&got)
+	if err == sql.ErrNoRows {
+		return false
+	}
+	if err != nil {
+		t.Fatalf("cannot check for table %s: %v", name, err)
+	}
+	return true
+}
+
+func TestRollbackUndoesAppliedMigration(t *testing.T) {
+	db := openSQLite(t)
+
+	m := sqlm.Migration{
+		Group:          "widgets",
+		Version:        1,
+		Statements:     []string{"CREATE TABLE widgets (id INTEGER PRIMARY KEY)"},
+		DownStatements: []string{"DROP TABLE widgets"},
+	}
+
+	if err := sqlm.Apply(db, m); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if !tableExists(t, db, "widgets") {
+		t.Fatalf("expected widgets table to exist after Apply")
+	}
+
+	if err := sqlm.Rollback(db, 0, "widgets"); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if tableExists(t, db, "widgets") {
+		t.Fatalf("expected widgets table to be gone after Rollback")
+	}
+
+	entries, err := sqlm.History(sqlm.SQLite, db)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected no history entries left after rollback, got %+v", entries)
+	}
+}
+
+func TestRollbackRefusesMigrationWithoutDownScript(t *testing.T) {
+	db := openSQLite(t)
+
+	m := sqlm.Migration{
+		Group:      "widgets",
+		Version:    1,
+		Statements: []string{"CREATE TABLE widgets (id INTEGER PRIMARY KEY)"},
+	}
+
+	if err := sqlm.Apply(db, m); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	err := sqlm.Rollback(db, 0, "widgets")
+	if err == nil {
+		t.Fatalf("expected Rollback to refuse a migration with no down script")
+	}
+	if !strings.Contains(err.Error(), "no recorded down script") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !tableExists(t, db, "widgets") {
+		t.Fatalf("widgets table should still exist, Rollback must not touch the schema on refusal")
+	}
+
+	entries, err := sqlm.History(sqlm.SQLite, db)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected the history entry to survive the refused rollback, got %+v", entries)
+	}
+}
@@ -0,0 +1,300 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sqlm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// ColumnDiff identifies a single column in a DriftReport.
+type ColumnDiff struct {
+	Table    string
+	Column   string
+	DataType string
+	Nullable bool
+}
+
+// IndexDiff identifies a single index in a DriftReport.
+type IndexDiff struct {
+	Table   string
+	Index   string
+	Columns []string
+	Unique  bool
+}
+
+// DriftReport is the result of Verify: everything that differs between what
+// the migrations produce and what target's live schema actually looks like.
+// It is plain exported data, so it serializes to JSON as-is for CI to gate
+// on.
+type DriftReport struct {
+	AddedTables    []string
+	RemovedTables  []string
+	AddedColumns   []ColumnDiff
+	RemovedColumns []ColumnDiff
+	AlteredColumns []ColumnDiff
+	AddedIndexes   []IndexDiff
+	RemovedIndexes []IndexDiff
+}
+
+// Clean reports whether no drift was found.
+func (r *DriftReport) Clean() bool {
+	return len(r.AddedTables) == 0 && len(r.RemovedTables) == 0 &&
+		len(r.AddedColumns) == 0 && len(r.RemovedColumns) == 0 && len(r.AlteredColumns) == 0 &&
+		len(r.AddedIndexes) == 0 && len(r.RemovedIndexes) == 0
+}
+
+// ScratchFactory creates an empty scratch database for Verify to apply the
+// full migration set into, and hands back a cleanup func that tears the
+// scratch database down again once Verify is done with it - e.g. dropping a
+// temporary schema, closing an in-memory SQLite handle, or removing a
+// throwaway docker container. Verify calls cleanup only when err is nil; on a
+// non-nil err it returns that error without calling cleanup, so cleanup may
+// be nil in that case.
+//
+// The returned *sql.DB is handed a real *sql.Tx by Verify before migrations
+// are applied to it, the same way MustMigrate does, so Type: Go migrations
+// registered via RegisterGo - which require a *sql.Tx - work against the
+// scratch database too.
+type ScratchFactory func() (scratch *sql.DB, cleanup func() error, err error)
+
+// Verify applies migrations into a fresh scratch database - created on
+// demand by newScratch, which must hand back something that starts out
+// empty, e.g. a temporary schema, an in-memory SQLite database, or a
+// throwaway container - and diffs the resulting schema against target, the
+// database believed to already run these migrations. A non-empty
+// DriftReport means target has drifted from what the migrations actually
+// produce, e.g. because an operator hand-edited production or a merge
+// dropped a migration.
+//
+// Verify only compares tables, columns and indexes; constraints (foreign
+// keys, checks) aren't introspected yet.
+func Verify(target DB, newScratch ScratchFactory, migrations ...Migration) (*DriftReport, error) {
+	scratchDB, cleanup, err := newScratch()
+	if err != nil {
+		return nil, fmt.Errorf("cannot create scratch database: %w", err)
+	}
+	defer func() {
+		if err := cleanup(); err != nil {
+			log.Errorf("cannot clean up scratch database: %v", err)
+		}
+	}()
+
+	// Migrations run inside a transaction, same as MustMigrate, so that Go
+	// migrations registered via RegisterGo get the *sql.Tx they require; the
+	// scratch database is thrown away via cleanup regardless, so there's
+	// nothing worth committing.
+	tx, err := scratchDB.BeginTx(context.Background(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot begin scratch transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := Apply(tx, migrations...); err != nil {
+		return nil, fmt.Errorf("cannot apply migrations into scratch database: %w", err)
+	}
+
+	scratchType, err := version(tx)
+	if err != nil {
+		return nil, fmt.Errorf("unknown scratch database type: %w", err)
+	}
+
+	targetType, err := version(target)
+	if err != nil {
+		return nil, fmt.Errorf("unknown target database type: %w", err)
+	}
+
+	scratchColumns, err := introspectColumns(scratchType, tx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot introspect scratch database: %w", err)
+	}
+
+	targetColumns, err := introspectColumns(targetType, target)
+	if err != nil {
+		return nil, fmt.Errorf("cannot introspect target database: %w", err)
+	}
+
+	scratchIndexes, err := introspectIndexes(scratchType, tx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot introspect scratch database: %w", err)
+	}
+
+	targetIndexes, err := introspectIndexes(targetType, target)
+	if err != nil {
+		return nil, fmt.Errorf("cannot introspect target database: %w", err)
+	}
+
+	return diffSchemas(scratchColumns, targetColumns, scratchIndexes, targetIndexes), nil
+}
+
+func introspectColumns(dbtype DBType, tx DB) ([]ColumnDiff, error) {
+	d, err := lookupDialect(dbtype)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(d.IntrospectColumns(tableName))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []ColumnDiff
+	for rows.Next() {
+		var col ColumnDiff
+		var isNullable string
+		if err := rows.Scan(&col.Table, &col.Column, &col.DataType, &isNullable); err != nil {
+			return nil, fmt.Errorf("cannot scan column: %w", err)
+		}
+		col.Nullable = isNullable == "YES"
+		res = append(res, col)
+	}
+	return res, rows.Err()
+}
+
+func introspectIndexes(dbtype DBType, tx DB) ([]IndexDiff, error) {
+	d, err := lookupDialect(dbtype)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(d.IntrospectIndexes(tableName))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byKey := make(map[[2]string]*IndexDiff)
+	var order [][2]string
+	for rows.Next() {
+		var table, index, column, isUnique string
+		if err := rows.Scan(&table, &index, &column, &isUnique); err != nil {
+			return nil, fmt.Errorf("cannot scan index: %w", err)
+		}
+
+		key := [2]string{table, index}
+		idx, ok := byKey[key]
+		if !ok {
+			idx = &IndexDiff{Table: table, Index: index, Unique: isUnique == "YES"}
+			byKey[key] = idx
+			order = append(order, key)
+		}
+		idx.Columns = append(idx.Columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	res := make([]IndexDiff, 0, len(order))
+	for _, key := range order {
+		res = append(res, *byKey[key])
+	}
+	return res, nil
+}
+
+func diffSchemas(scratchColumns, targetColumns []ColumnDiff, scratchIndexes, targetIndexes []IndexDiff) *DriftReport {
+	report := &DriftReport{}
+
+	scratchTables := tableSet(scratchColumns)
+	targetTables := tableSet(targetColumns)
+	for t := range scratchTables {
+		if !targetTables[t] {
+			report.AddedTables = append(report.AddedTables, t)
+		}
+	}
+	for t := range targetTables {
+		if !scratchTables[t] {
+			report.RemovedTables = append(report.RemovedTables, t)
+		}
+	}
+	sort.Strings(report.AddedTables)
+	sort.Strings(report.RemovedTables)
+
+	targetByKey := make(map[[2]string]ColumnDiff, len(targetColumns))
+	for _, c := range targetColumns {
+		targetByKey[[2]string{c.Table, c.Column}] = c
+	}
+	scratchByKey := make(map[[2]string]bool, len(scratchColumns))
+	for _, c := range scratchColumns {
+		scratchByKey[[2]string{c.Table, c.Column}] = true
+		key := [2]string{c.Table, c.Column}
+		if existing, ok := targetByKey[key]; !ok {
+			if targetTables[c.Table] {
+				report.AddedColumns = append(report.AddedColumns, c)
+			}
+		} else if existing.DataType != c.DataType || existing.Nullable != c.Nullable {
+			report.AlteredColumns = append(report.AlteredColumns, c)
+		}
+	}
+	for _, c := range targetColumns {
+		if scratchTables[c.Table] && !scratchByKey[[2]string{c.Table, c.Column}] {
+			report.RemovedColumns = append(report.RemovedColumns, c)
+		}
+	}
+	sortColumns(report.AddedColumns)
+	sortColumns(report.RemovedColumns)
+	sortColumns(report.AlteredColumns)
+
+	targetIdxByKey := make(map[[2]string]bool, len(targetIndexes))
+	for _, idx := range targetIndexes {
+		targetIdxByKey[[2]string{idx.Table, idx.Index}] = true
+	}
+	scratchIdxByKey := make(map[[2]string]bool, len(scratchIndexes))
+	for _, idx := range scratchIndexes {
+		scratchIdxByKey[[2]string{idx.Table, idx.Index}] = true
+		if !targetIdxByKey[[2]string{idx.Table, idx.Index}] {
+			report.AddedIndexes = append(report.AddedIndexes, idx)
+		}
+	}
+	for _, idx := range targetIndexes {
+		if !scratchIdxByKey[[2]string{idx.Table, idx.Index}] {
+			report.RemovedIndexes = append(report.RemovedIndexes, idx)
+		}
+	}
+	sortIndexes(report.AddedIndexes)
+	sortIndexes(report.RemovedIndexes)
+
+	return report
+}
+
+func tableSet(columns []ColumnDiff) map[string]bool {
+	res := make(map[string]bool)
+	for _, c := range columns {
+		res[c.Table] = true
+	}
+	return res
+}
+
+func sortColumns(columns []ColumnDiff) {
+	sort.Slice(columns, func(i, j int) bool {
+		if columns[i].Table != columns[j].Table {
+			return columns[i].Table < columns[j].Table
+		}
+		return columns[i].Column < columns[j].Column
+	})
+}
+
+func sortIndexes(indexes []IndexDiff) {
+	sort.Slice(indexes, func(i, j int) bool {
+		if indexes[i].Table != indexes[j].Table {
+			return indexes[i].Table < indexes[j].Table
+		}
+		return indexes[i].Index < indexes[j].Index
+	})
+}
@@ -0,0 +1,185 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sqlm_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/worldiety/sqlm"
+)
+
+func sqliteScratchFactory(t *testing.T) sqlm.ScratchFactory {
+	t.Helper()
+	return func() (*sql.DB, func() error, error) {
+		db := openSQLite(t)
+		return db, func() error { return nil }, nil
+	}
+}
+
+func TestVerifyCleanWhenTargetMatchesMigrations(t *testing.T) {
+	target := openSQLite(t)
+	migrations := []sqlm.Migration{{
+		Group:      "widgets",
+		Version:    1,
+		Statements: []string{"CREATE TABLE widgets (id INTEGER PRIMARY KEY, name TEXT NOT NULL)"},
+	}}
+	if err := sqlm.Apply(target, migrations...); err != nil {
+		t.Fatalf("cannot seed target: %v", err)
+	}
+
+	report, err := sqlm.Verify(target, sqliteScratchFactory(t), migrations...)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !report.Clean() {
+		t.Fatalf("expected no drift, got %+v", report)
+	}
+}
+
+func TestVerifyAppliesGoMigrationsAgainstScratch(t *testing.T) {
+	target := openSQLite(t)
+	migration := sqlm.RegisterGo("widgets", 1, "create_widgets",
+		func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "CREATE TABLE widgets (id INTEGER PRIMARY KEY)")
+			return err
+		},
+		func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "DROP TABLE widgets")
+			return err
+		})
+	tx, err := target.Begin()
+	if err != nil {
+		t.Fatalf("cannot begin seeding transaction: %v", err)
+	}
+	if err := sqlm.Apply(tx, migration); err != nil {
+		t.Fatalf("cannot seed target: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("cannot commit seeding transaction: %v", err)
+	}
+
+	report, err := sqlm.Verify(target, sqliteScratchFactory(t), migration)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !report.Clean() {
+		t.Fatalf("expected no drift, got %+v", report)
+	}
+}
+
+func TestVerifyReportsTableAndColumnDrift(t *testing.T) {
+	target := openSQLite(t)
+	if _, err := target.Exec(`CREATE TABLE widgets (id INTEGER PRIMARY KEY, name VARCHAR(255) NOT NULL)`); err != nil {
+		t.Fatalf("cannot seed target: %v", err)
+	}
+	if _, err := target.Exec(`CREATE TABLE gizmos (id INTEGER PRIMARY KEY)`); err != nil {
+		t.Fatalf("cannot seed target: %v", err)
+	}
+
+	migrations := []sqlm.Migration{{
+		Group:   "widgets",
+		Version: 1,
+		Statements: []string{
+			"CREATE TABLE widgets (id INTEGER PRIMARY KEY, name INTEGER NOT NULL)",
+			"CREATE TABLE gadgets (id INTEGER PRIMARY KEY)",
+		},
+	}}
+
+	report, err := sqlm.Verify(target, sqliteScratchFactory(t), migrations...)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+
+	if len(report.AddedTables) != 1 || report.AddedTables[0] != "gadgets" {
+		t.Errorf("expected AddedTables=[gadgets], got %v", report.AddedTables)
+	}
+	if len(report.RemovedTables) != 1 || report.RemovedTables[0] != "gizmos" {
+		t.Errorf("expected RemovedTables=[gizmos], got %v", report.RemovedTables)
+	}
+	if len(report.AlteredColumns) != 1 || report.AlteredColumns[0].Column != "name" {
+		t.Errorf("expected widgets.name to be altered, got %+v", report.AlteredColumns)
+	}
+	if report.Clean() {
+		t.Errorf("expected drift to be reported")
+	}
+}
+
+func TestVerifyReportsIndexDrift(t *testing.T) {
+	target := openSQLite(t)
+	if _, err := target.Exec(`CREATE TABLE widgets (id INTEGER PRIMARY KEY, sku TEXT)`); err != nil {
+		t.Fatalf("cannot seed target: %v", err)
+	}
+	if _, err := target.Exec(`CREATE INDEX idx_widgets_old ON widgets (sku)`); err != nil {
+		t.Fatalf("cannot seed target: %v", err)
+	}
+
+	migrations := []sqlm.Migration{{
+		Group:   "widgets",
+		Version: 1,
+		Statements: []string{
+			"CREATE TABLE widgets (id INTEGER PRIMARY KEY, sku TEXT)",
+			"CREATE UNIQUE INDEX idx_widgets_sku ON widgets (sku)",
+		},
+	}}
+
+	report, err := sqlm.Verify(target, sqliteScratchFactory(t), migrations...)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+
+	if len(report.AddedIndexes) != 1 || report.AddedIndexes[0].Index != "idx_widgets_sku" {
+		t.Errorf("expected AddedIndexes=[idx_widgets_sku], got %v", report.AddedIndexes)
+	}
+	if len(report.RemovedIndexes) != 1 || report.RemovedIndexes[0].Index != "idx_widgets_old" {
+		t.Errorf("expected RemovedIndexes=[idx_widgets_old], got %v", report.RemovedIndexes)
+	}
+}
+
+func TestVerifyPropagatesScratchFactoryError(t *testing.T) {
+	target := openSQLite(t)
+	wantErr := errors.New("boom")
+
+	_, err := sqlm.Verify(target, func() (*sql.DB, func() error, error) {
+		return nil, nil, wantErr
+	})
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("expected Verify to propagate the factory error, got %v", err)
+	}
+}
+
+func TestVerifyRunsCleanupEvenOnApplyFailure(t *testing.T) {
+	target := openSQLite(t)
+
+	var cleaned bool
+	factory := func() (*sql.DB, func() error, error) {
+		return openSQLite(t), func() error {
+			cleaned = true
+			return nil
+		}, nil
+	}
+
+	badMigration := sqlm.Migration{Group: "widgets", Version: 1, Statements: []string{"NOT VALID SQL"}}
+	if _, err := sqlm.Verify(target, factory, badMigration); err == nil {
+		t.Fatalf("expected Verify to fail on a bad migration")
+	}
+	if !cleaned {
+		t.Errorf("expected the scratch database to be cleaned up even after a failed Apply")
+	}
+}